@@ -6,12 +6,18 @@
 package ca
 
 import (
+	"crypto"
+	"crypto/rand"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
+	"log"
+	"math/big"
 	"time"
 
 	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/ct"
+	"github.com/letsencrypt/boulder/ocsp"
 
 	"github.com/cloudflare/cfssl/auth"
 	"github.com/cloudflare/cfssl/config"
@@ -20,17 +26,34 @@ import (
 )
 
 type CertificateAuthorityImpl struct {
-	profile string
-	Signer  signer.Signer
-	SA      core.StorageAuthority
+	profiles       map[string]Profile
+	defaultProfile string
+	Signer         signer.Signer
+	SA             core.StorageAuthority
+	// OCSP signs the initial "good" OCSP response at issuance time, if
+	// set. It is optional: a CA with no OCSP responder configured simply
+	// skips this step.
+	OCSP *ocsp.OCSPSigner
+
+	// issuerCert and issuerKey are only populated for local-signer CAs
+	// (see NewLocalCertificateAuthorityImpl); they back GenerateCRL, which
+	// a remote CFSSL signer has no equivalent support for.
+	issuerCert *x509.Certificate
+	issuerKey  crypto.Signer
+
+	// CT, if set, makes IssueCertificate submit a pre-certificate to CT
+	// logs and embed the resulting SCTs before issuing the final
+	// certificate.
+	CT *CTConfig
 }
 
 // NewCertificateAuthorityImpl creates a CA that talks to a remote CFSSL
 // instance.  (To use a local signer, simply instantiate CertificateAuthorityImpl
 // directly.)  Communications with the CA are authenticated with MACs,
 // using CFSSL's authenticated signature scheme.  A CA created in this way
-// issues for a single profile on the remote signer, which is indicated
-// by name in this constructor.
+// issues against a single named profile on the remote signer, with no
+// additional constraints of its own; use NewLocalCertificateAuthorityImpl
+// to host several tenant-scoped profiles from one CA process.
 func NewCertificateAuthorityImpl(hostport string, authKey string, profile string) (ca *CertificateAuthorityImpl, err error) {
 	// Create the remote signer
 	localProfile := config.SigningProfile{
@@ -48,18 +71,49 @@ func NewCertificateAuthorityImpl(hostport string, authKey string, profile string
 		return
 	}
 
-	ca = &CertificateAuthorityImpl{Signer: signer, profile: profile}
+	ca = &CertificateAuthorityImpl{
+		Signer:         signer,
+		profiles:       map[string]Profile{profile: {Name: profile}},
+		defaultProfile: profile,
+	}
 	return
 }
 
-func (ca *CertificateAuthorityImpl) IssueCertificate(csr x509.CertificateRequest) (cert core.Certificate, err error) {
-	// XXX Take in authorizations and verify that union covers CSR?
-	// Pull hostnames from CSR
-	hostNames := csr.DNSNames // DNSNames + CN from CSR
-	if len(hostNames) < 1 {
-		err = errors.New("Cannot issue a certificate without a hostname.")
+// IssueCertificate signs csr and returns the resulting certificate.  regID
+// identifies the ACME registration requesting issuance, authzs must
+// contain a still-valid, core.StatusValid authorization for every DNSName
+// (and CommonName, if set) present in the CSR, and profileName selects
+// which of the CA's configured Profiles governs this issuance (the RA, not
+// the CA, decides which profile a request should use).  An empty
+// profileName falls back to the CA's defaultProfile. Issuance is refused
+// if the union of authzs does not cover the CSR, or if the CSR violates
+// the selected profile's name constraints or key type restriction.
+func (ca *CertificateAuthorityImpl) IssueCertificate(csr x509.CertificateRequest, regID int64, authzs []core.Authorization, profileName string) (cert core.Certificate, err error) {
+	hostNames, keyAlgorithm, err := validateCSR(&csr)
+	if err != nil {
+		return
+	}
+
+	usedAuthzIDs, err := checkAuthorizations(hostNames, regID, authzs)
+	if err != nil {
+		return
+	}
+
+	if profileName == "" {
+		profileName = ca.defaultProfile
+	}
+	profile, ok := ca.profiles[profileName]
+	if !ok {
+		err = errors.New("Unknown issuance profile: " + profileName)
 		return
 	}
+	if err = checkNameConstraints(profile.Constraints, hostNames); err != nil {
+		return
+	}
+	if err = checkKeyType(profile, keyAlgorithm); err != nil {
+		return
+	}
+
 	var commonName string
 	if len(csr.Subject.CommonName) > 0 {
 		commonName = csr.Subject.CommonName
@@ -73,15 +127,53 @@ func (ca *CertificateAuthorityImpl) IssueCertificate(csr x509.CertificateRequest
 		Bytes: csr.Raw,
 	}))
 
+	// Fix the serial and validity window now, rather than letting the
+	// signer pick them fresh on every Sign call: when ca.CT is configured
+	// below, the pre-cert and the final cert are two separate SignRequests,
+	// and they must share an identical TBS (modulo the poison/SCT-list
+	// extension) for the SCTs a log signs over the pre-cert to validate
+	// against the final certificate.
+	notBefore := time.Now()
+	serial, err := newSerial()
+	if err != nil {
+		return
+	}
+
 	// Send the cert off for signing
 	req := signer.SignRequest{
-		Request: csrPEM,
-		Profile: ca.profile,
-		Hosts:   hostNames,
+		Request:   csrPEM,
+		Profile:   profile.Name,
+		Hosts:     hostNames,
+		Serial:    serial,
+		NotBefore: notBefore,
 		Subject: &signer.Subject{
 			CN: commonName,
 		},
 	}
+	if profile.MaxValidity > 0 {
+		req.NotAfter = notBefore.Add(profile.MaxValidity)
+	}
+	if profile.CRLURL != "" {
+		crlExt, crlErr := crlDistributionPointsExtension(profile.CRLURL)
+		if crlErr != nil {
+			err = crlErr
+			return
+		}
+		req.Extensions = append(req.Extensions, crlExt)
+	}
+
+	var scts []ct.SCT
+	if ca.CT != nil {
+		var sctExtension signer.Extension
+		var ctErr error
+		scts, sctExtension, ctErr = ca.submitPreCertAndEmbed(req)
+		if ctErr != nil {
+			err = ctErr
+			return
+		}
+		req.Extensions = append(req.Extensions, sctExtension)
+	}
+
 	certPEM, err := ca.Signer.Sign(req)
 	if err != nil {
 		return
@@ -99,16 +191,59 @@ func (ca *CertificateAuthorityImpl) IssueCertificate(csr x509.CertificateRequest
 	}
 	certDER := block.Bytes
 
-	// Store the cert with the certificate authority, if provided
-	certID, err := ca.SA.AddCertificate(certDER)
+	// Store the cert, along with the registration and authorizations that
+	// justified its issuance, so audits can reconstruct why it was signed.
+	certID, err := ca.SA.AddCertificate(certDER, regID, usedAuthzIDs)
 	if err != nil {
 		return
 	}
 
+	if ca.OCSP != nil {
+		if ocspErr := ca.preSignOCSPResponse(certDER); ocspErr != nil {
+			// A missing initial OCSP response is not fatal to issuance;
+			// the Updater will backfill it on its next tick.
+			log.Printf("ca: failed to pre-sign OCSP response: %s", ocspErr)
+		}
+	}
+
+	if len(scts) > 0 {
+		if sctErr := ca.SA.AddSCTReceipts(certID, scts); sctErr != nil {
+			// The cert is already valid and stored; a failure to persist
+			// the SCTs is logged, not propagated, since the certificate
+			// itself did embed them.
+			log.Printf("ca: failed to persist SCT receipts: %s", sctErr)
+		}
+	}
+
 	cert = core.Certificate{
 		ID:     certID,
 		DER:    certDER,
 		Status: core.StatusValid,
 	}
 	return
+}
+
+// preSignOCSPResponse parses the just-issued certificate back out of
+// certDER, signs an initial "good" OCSP response for it via ca.OCSP, and
+// stores the response in the SA keyed by serial.
+func (ca *CertificateAuthorityImpl) preSignOCSPResponse(certDER []byte) error {
+	parsed, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return err
+	}
+	response, err := ca.OCSP.SignGood(parsed)
+	if err != nil {
+		return err
+	}
+	return ca.SA.AddOCSPResponse(parsed.SerialNumber.Bytes(), response, time.Now().Add(ca.OCSP.Interval))
+}
+
+// serialBits is the size of generated certificate serial numbers. 128 bits
+// comfortably exceeds the 64 bits of entropy CA/Browser Forum guidelines
+// require.
+const serialBits = 128
+
+// newSerial generates a random positive certificate serial number.
+func newSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), serialBits))
 }
\ No newline at end of file
@@ -0,0 +1,210 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/ct"
+
+	"github.com/cloudflare/cfssl/signer"
+)
+
+// testIssuer is a self-signed issuer cert/key pair used to back fakeSigner
+// across this file's tests.
+type testIssuer struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating issuer key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Issuer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour * 24 * 365),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating issuer cert: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing issuer cert: %s", err)
+	}
+	return &testIssuer{cert: cert, key: key}
+}
+
+// fakeSigner is a signer.Signer that actually signs leaf certificates with
+// a testIssuer, so tests can verify the resulting chain the same way a real
+// CFSSL signer's output would be verified. Embedding signer.Signer lets it
+// satisfy the interface without reimplementing methods IssueCertificate
+// never calls (Info, Policy, SetDBAccessor, SetPolicy).
+type fakeSigner struct {
+	signer.Signer
+	issuer *testIssuer
+}
+
+func (fs *fakeSigner) Sign(req signer.SignRequest) ([]byte, error) {
+	block, _ := pem.Decode([]byte(req.Request))
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: req.Serial,
+		Subject:      csr.Subject,
+		DNSNames:     req.Hosts,
+		NotBefore:    req.NotBefore,
+		NotAfter:     req.NotAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if template.NotAfter.IsZero() {
+		template.NotAfter = req.NotBefore.Add(90 * 24 * time.Hour)
+	}
+	for _, ext := range req.Extensions {
+		value, err := hex.DecodeString(ext.Value)
+		if err != nil {
+			return nil, err
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{
+			Id:       asn1.ObjectIdentifier(ext.ID),
+			Critical: ext.Critical,
+			Value:    value,
+		})
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, fs.issuer.cert, csr.PublicKey, fs.issuer.key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// fakeSA is a core.StorageAuthority that records what IssueCertificate
+// persists. Embedding the interface satisfies every method this test
+// doesn't care about; only the three IssueCertificate actually calls are
+// overridden.
+type fakeSA struct {
+	core.StorageAuthority
+	addedDER      []byte
+	addedRegID    int64
+	addedAuthzIDs []string
+}
+
+func (f *fakeSA) AddCertificate(der []byte, regID int64, authzIDs []string) (string, error) {
+	f.addedDER = der
+	f.addedRegID = regID
+	f.addedAuthzIDs = authzIDs
+	return "cert-id-1", nil
+}
+
+func (f *fakeSA) AddOCSPResponse(serial, response []byte, nextUpdate time.Time) error {
+	return nil
+}
+
+func (f *fakeSA) AddSCTReceipts(certID string, scts []ct.SCT) error {
+	return nil
+}
+
+func testCSR(t *testing.T, commonName string, hosts []string) x509.CertificateRequest {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating CSR key: %s", err)
+	}
+	template := &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: commonName},
+		DNSNames: hosts,
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		t.Fatalf("creating CSR: %s", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatalf("parsing CSR: %s", err)
+	}
+	return *csr
+}
+
+func TestIssueCertificateChainsToIssuer(t *testing.T) {
+	issuer := newTestIssuer(t)
+	sa := &fakeSA{}
+	ca := &CertificateAuthorityImpl{
+		Signer:         &fakeSigner{issuer: issuer},
+		SA:             sa,
+		profiles:       map[string]Profile{"default": {Name: "default"}},
+		defaultProfile: "default",
+	}
+
+	csr := testCSR(t, "example.com", []string{"example.com"})
+	authzs := []core.Authorization{
+		{
+			RegistrationID: 1,
+			Status:         core.StatusValid,
+			Expires:        time.Now().Add(time.Hour),
+			Identifier:     core.AcmeIdentifier{Type: core.IdentifierDNS, Value: "example.com"},
+		},
+	}
+
+	cert, err := ca.IssueCertificate(csr, 1, authzs, "")
+	if err != nil {
+		t.Fatalf("IssueCertificate: %s", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.DER)
+	if err != nil {
+		t.Fatalf("parsing issued cert: %s", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(issuer.cert)
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: "example.com"}); err != nil {
+		t.Fatalf("issued certificate did not chain to test issuer: %s", err)
+	}
+
+	if sa.addedRegID != 1 {
+		t.Errorf("AddCertificate called with regID %d, want 1", sa.addedRegID)
+	}
+	if len(sa.addedAuthzIDs) != 1 {
+		t.Errorf("AddCertificate called with %d authz IDs, want 1", len(sa.addedAuthzIDs))
+	}
+}
+
+func TestIssueCertificateRequiresCoveringAuthorization(t *testing.T) {
+	issuer := newTestIssuer(t)
+	ca := &CertificateAuthorityImpl{
+		Signer:         &fakeSigner{issuer: issuer},
+		SA:             &fakeSA{},
+		profiles:       map[string]Profile{"default": {Name: "default"}},
+		defaultProfile: "default",
+	}
+
+	csr := testCSR(t, "example.com", []string{"example.com"})
+
+	if _, err := ca.IssueCertificate(csr, 1, nil, ""); err == nil {
+		t.Fatal("IssueCertificate succeeded with no authorizations, want error")
+	}
+}
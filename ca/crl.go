@@ -0,0 +1,81 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ca
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// RevokeCertificate marks the certificate with the given serial as revoked
+// for the given CRL reason code (see RFC 5280 §5.3.1). The actual
+// bookkeeping lives in the SA; this just forwards the request and dates
+// the revocation as of now.
+func (ca *CertificateAuthorityImpl) RevokeCertificate(serial []byte, reason int) error {
+	return ca.SA.MarkCertificateRevoked(serial, reason, time.Now())
+}
+
+// GenerateCRL signs a full CRL, covering every StatusRevoked certificate
+// known to the SA, valid from thisUpdate to nextUpdate.  GenerateCRL
+// requires a local-signer CA (one constructed via
+// NewLocalCertificateAuthorityImpl): a remote CFSSL signer has no CRL
+// support, since Boulder itself owns the revoked-certificate list.
+func (ca *CertificateAuthorityImpl) GenerateCRL(ctx context.Context, thisUpdate, nextUpdate time.Time) ([]byte, error) {
+	return ca.GenerateCRLShard(ctx, 0, 1, thisUpdate, nextUpdate)
+}
+
+// GenerateCRLShard signs one shard of a sharded CRL: the shard covering
+// certificates whose serial number is congruent to shard, mod shards.
+// Passing shards == 1 produces a single, unsharded CRL.
+func (ca *CertificateAuthorityImpl) GenerateCRLShard(ctx context.Context, shard, shards int, thisUpdate, nextUpdate time.Time) ([]byte, error) {
+	if ca.issuerCert == nil || ca.issuerKey == nil {
+		return nil, errors.New("CRL generation requires a local-signer CA with an issuer key")
+	}
+	if shards < 1 {
+		shards = 1
+	}
+
+	revoked, err := ca.SA.GetRevokedCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []pkix.RevokedCertificate
+	for _, r := range revoked {
+		serial := new(big.Int).SetBytes(r.Serial)
+		if shards > 1 && new(big.Int).Mod(serial, big.NewInt(int64(shards))).Int64() != int64(shard) {
+			continue
+		}
+		entries = append(entries, pkix.RevokedCertificate{
+			SerialNumber:   serial,
+			RevocationTime: r.RevokedAt,
+			Extensions: []pkix.Extension{
+				crlReasonExtension(r.RevocationReason),
+			},
+		})
+	}
+
+	return ca.issuerCert.CreateCRL(rand.Reader, ca.issuerKey, entries, thisUpdate, nextUpdate)
+}
+
+// crlReasonOID is the X.509 CRL entry extension OID for reasonCode
+// (RFC 5280 §5.3.1).
+var crlReasonOID = []int{2, 5, 29, 21}
+
+// crlReasonExtension encodes reason as a CRL entry reasonCode extension.
+func crlReasonExtension(reason int) pkix.Extension {
+	// CRLReason is an ASN.1 ENUMERATED, which encodes identically to an
+	// INTEGER for values in this range.
+	value := []byte{0x0a, 0x01, byte(reason)}
+	return pkix.Extension{
+		Id:    crlReasonOID,
+		Value: value,
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+)
+
+// maxNames is the maximum number of DNSNames (including the CN, if
+// distinct) that IssueCertificate will sign into a single certificate.
+const maxNames = 100
+
+// minRSAKeyBits is the smallest RSA modulus IssueCertificate will accept
+// in a CSR's public key.
+const minRSAKeyBits = 2048
+
+// dedupeHosts canonicalizes a list of hostnames (lowercasing and
+// stripping any trailing dot) and removes duplicates, preserving order.
+// It plays the same role for CSR host lists that signer.SplitHosts plays
+// for CFSSL's comma-separated host flag.
+func dedupeHosts(hosts []string) []string {
+	seen := make(map[string]bool, len(hosts))
+	out := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimSuffix(h, "."))
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		out = append(out, h)
+	}
+	return out
+}
+
+// wellFormedNames rejects empty hostnames and hostnames containing a "*".
+// Boulder does not issue wildcard certificates. Hostname syntax is not
+// otherwise checked.
+func wellFormedNames(hosts []string) error {
+	for _, h := range hosts {
+		if h == "" {
+			return errors.New("Empty hostname in CSR.")
+		}
+		if strings.HasPrefix(h, "*.") || strings.Contains(h, "*") {
+			return errors.New("Wildcard names are not allowed: " + h)
+		}
+	}
+	return nil
+}
+
+// checkCSRKey validates that the CSR's public key meets Boulder's minimum
+// strength requirements: RSA keys must be at least minRSAKeyBits bits, and
+// ECDSA keys must be on the P-256, P-384, or P-521 curves.  It returns a
+// short algorithm name ("rsa" or "ecdsa") for use in per-profile key type
+// checks.
+func checkCSRKey(csr *x509.CertificateRequest) (string, error) {
+	switch k := csr.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return "", errors.New("Key too small: RSA keys must be at least 2048 bits.")
+		}
+		return "rsa", nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+		default:
+			return "", errors.New("Unsupported ECDSA curve.")
+		}
+		return "ecdsa", nil
+	default:
+		return "", errors.New("Unsupported public key algorithm.")
+	}
+}
+
+// authzCoversName reports whether authz is a still-valid authorization,
+// belonging to regID, for the exact DNS identifier name.
+func authzCoversName(authz core.Authorization, regID int64, name string) bool {
+	if authz.RegistrationID != regID {
+		return false
+	}
+	if authz.Status != core.StatusValid {
+		return false
+	}
+	if authz.Expires.Before(time.Now()) {
+		return false
+	}
+	if authz.Identifier.Type != core.IdentifierDNS {
+		return false
+	}
+	return strings.EqualFold(authz.Identifier.Value, name)
+}
+
+// checkAuthorizations verifies that, for every hostname in names, there is
+// at least one authorization in authzs that covers it for regID.  It
+// returns the IDs of the authorizations actually used, so callers can
+// persist the (regID, serial, authz IDs) linkage.
+func checkAuthorizations(names []string, regID int64, authzs []core.Authorization) ([]string, error) {
+	usedIDs := make([]string, 0, len(names))
+	for _, name := range names {
+		var covered bool
+		for _, authz := range authzs {
+			if authzCoversName(authz, regID, name) {
+				usedIDs = append(usedIDs, authz.ID)
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return nil, errors.New("Authorizations don't cover all names in CSR: missing " + name)
+		}
+	}
+	return usedIDs, nil
+}
+
+// validateCSR canonicalizes and validates the hostnames and key in csr,
+// rejecting wildcards, IP SANs, oversized SAN lists, and weak keys.  It
+// returns the deduped, lowercased list of hostnames to be signed along
+// with the CSR's key algorithm, for use in per-profile key type checks.
+func validateCSR(csr *x509.CertificateRequest) ([]string, string, error) {
+	if len(csr.IPAddresses) > 0 {
+		return nil, "", errors.New("IP address SANs are not allowed.")
+	}
+
+	names := dedupeHosts(csr.DNSNames)
+	if len(csr.Subject.CommonName) > 0 {
+		names = dedupeHosts(append([]string{csr.Subject.CommonName}, names...))
+	}
+
+	if len(names) < 1 {
+		return nil, "", errors.New("Cannot issue a certificate without a hostname.")
+	}
+	if len(names) > maxNames {
+		return nil, "", errors.New("CSR names exceed the maximum allowed per certificate.")
+	}
+
+	if err := wellFormedNames(names); err != nil {
+		return nil, "", err
+	}
+
+	keyAlgorithm, err := checkCSRKey(csr)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return names, keyAlgorithm, nil
+}
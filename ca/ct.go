@@ -0,0 +1,122 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ca
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"time"
+
+	"github.com/letsencrypt/boulder/ct"
+
+	"github.com/cloudflare/cfssl/config"
+	"github.com/cloudflare/cfssl/signer"
+)
+
+// defaultMinSCTs is how many independent SCTs IssueCertificate requires
+// before it will embed them and issue the final certificate, when CTConfig
+// doesn't override it.
+const defaultMinSCTs = 2
+
+// defaultCTDeadline bounds how long IssueCertificate will wait for the SCT
+// quorum before failing issuance.
+const defaultCTDeadline = 10 * time.Second
+
+// CTConfig configures the CT pre-cert + SCT embedding step of
+// IssueCertificate. A CertificateAuthorityImpl with a nil CT performs no
+// CT submission and issues certificates directly, as before.
+type CTConfig struct {
+	Submitter ct.CTSubmitter
+	Logs      []ct.LogConfig
+	// MinSCTs is the number of distinct log operators (see
+	// LogConfig.Operator) that must return an SCT before issuance
+	// proceeds. Zero means defaultMinSCTs.
+	MinSCTs int
+	// Deadline bounds the whole submission round, across all logs. Zero
+	// means defaultCTDeadline.
+	Deadline time.Duration
+}
+
+func (cfg *CTConfig) minSCTs() int {
+	if cfg.MinSCTs > 0 {
+		return cfg.MinSCTs
+	}
+	return defaultMinSCTs
+}
+
+func (cfg *CTConfig) deadline() time.Duration {
+	if cfg.Deadline > 0 {
+		return cfg.Deadline
+	}
+	return defaultCTDeadline
+}
+
+// signPreCert asks ca.Signer for a poisoned pre-certificate. req must
+// already carry an explicit Serial and NotBefore (see IssueCertificate),
+// so that the final certificate signed from the same req (with the poison
+// extension swapped for the SCT-list extension) shares an identical TBS
+// with this pre-cert, modulo that one extension: a log's SCT is a
+// signature over the pre-cert's TBS, and it only validates against a final
+// certificate whose TBS matches once the poison extension is removed.
+func (ca *CertificateAuthorityImpl) signPreCert(req signer.SignRequest) ([]byte, error) {
+	req.Extensions = append([]signer.Extension{}, req.Extensions...)
+	req.Extensions = append(req.Extensions, signer.Extension{
+		ID:       config.OID(ct.PoisonExtensionOID),
+		Critical: true,
+		Value:    hex.EncodeToString(ct.PoisonExtensionValue),
+	})
+
+	preCertPEM, err := ca.Signer.Sign(req)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(preCertPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("CT: invalid pre-certificate value returned by signer")
+	}
+	return block.Bytes, nil
+}
+
+// submitPreCertAndEmbed runs the pre-cert + SCT collection step and
+// returns both the collected SCTs (for the SA to persist alongside the
+// cert) and the extension to append to the final SignRequest. It requires
+// ca.CT to be configured and an issuer certificate to chain the
+// pre-certificate to, which is only available on local-signer CAs.
+func (ca *CertificateAuthorityImpl) submitPreCertAndEmbed(req signer.SignRequest) ([]ct.SCT, signer.Extension, error) {
+	if ca.issuerCert == nil {
+		return nil, signer.Extension{}, errors.New("CT submission requires a local-signer CA with an issuer certificate")
+	}
+
+	preCertDER, err := ca.signPreCert(req)
+	if err != nil {
+		return nil, signer.Extension{}, err
+	}
+
+	scts, err := ct.SubmitToLogs(
+		context.Background(),
+		ca.CT.Submitter,
+		ca.CT.Logs,
+		preCertDER,
+		ca.issuerCert.Raw,
+		ca.CT.minSCTs(),
+		ca.CT.deadline(),
+	)
+	if err != nil {
+		return nil, signer.Extension{}, err
+	}
+
+	value, err := ct.EncodeSCTListExtensionValue(scts)
+	if err != nil {
+		return nil, signer.Extension{}, err
+	}
+
+	return scts, signer.Extension{
+		ID:    config.OID(ct.SCTListExtensionOID),
+		Value: hex.EncodeToString(value),
+	}, nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ca
+
+import (
+	"crypto"
+	"encoding/asn1"
+	"errors"
+	"io/ioutil"
+
+	"github.com/letsencrypt/boulder/ct"
+
+	"github.com/cloudflare/cfssl/config"
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/cloudflare/cfssl/signer"
+	"github.com/cloudflare/cfssl/signer/local"
+	"github.com/letsencrypt/pkcs11key"
+)
+
+// IssuerKeySource loads the private key corresponding to the CA's issuer
+// certificate.  Implementations may read the key from disk, from an HSM
+// over PKCS#11, or from any other key custodian.
+// NewLocalCertificateAuthorityImpl obtains the key through this interface
+// and hands it to the local signer; IssueCertificate never touches the key
+// material directly.
+type IssuerKeySource interface {
+	// IssuerKey returns the private key to be used for signing.  It may be
+	// called more than once, so implementations that talk to an HSM should
+	// cache the handle rather than re-logging in on every call.
+	IssuerKey() (crypto.Signer, error)
+}
+
+// FileIssuerKeySource loads a PEM-encoded private key from the local
+// filesystem.
+type FileIssuerKeySource struct {
+	Filename string
+}
+
+// IssuerKey implements IssuerKeySource.
+func (fiks FileIssuerKeySource) IssuerKey() (crypto.Signer, error) {
+	keyBytes, err := ioutil.ReadFile(fiks.Filename)
+	if err != nil {
+		return nil, err
+	}
+	key, err := helpers.ParsePrivateKeyPEM(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+	signerKey, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("issuer key does not support signing")
+	}
+	return signerKey, nil
+}
+
+// PKCS11IssuerKeySource loads the issuer private key from a PKCS#11 token,
+// e.g. an HSM, identified by a module path, token label, PIN, and private
+// key object label. The session is established lazily on the first call
+// to IssuerKey and reused thereafter.
+type PKCS11IssuerKeySource struct {
+	// Module is the path to the PKCS#11 module (.so) to load.
+	Module string
+	// TokenLabel identifies which token on the device to open a session
+	// against.
+	TokenLabel string
+	// PIN authenticates the session to the token.
+	PIN string
+	// PrivateKeyLabel is the CKA_LABEL of the private key object to use
+	// for signing.
+	PrivateKeyLabel string
+
+	session crypto.Signer
+}
+
+// IssuerKey implements IssuerKeySource by opening (and caching) a session
+// against the configured PKCS#11 token via Boulder's pkcs11key package.
+func (pks *PKCS11IssuerKeySource) IssuerKey() (crypto.Signer, error) {
+	if pks.session != nil {
+		return pks.session, nil
+	}
+	key, err := pkcs11key.New(pks.Module, pks.TokenLabel, pks.PIN, pks.PrivateKeyLabel)
+	if err != nil {
+		return nil, err
+	}
+	pks.session = key
+	return pks.session, nil
+}
+
+// LocalCAConfig configures a CertificateAuthorityImpl that signs
+// certificates itself, in-process, rather than delegating to a remote
+// CFSSL signer.
+type LocalCAConfig struct {
+	// IssuerCertFile is the path to the PEM-encoded issuer (intermediate)
+	// certificate.
+	IssuerCertFile string
+	// KeySource supplies the private key matching IssuerCertFile.  Use a
+	// FileIssuerKeySource for a key on disk, or a PKCS11IssuerKeySource to
+	// keep the intermediate key in an HSM.
+	KeySource IssuerKeySource
+	// CFSSLConfigFile is the path to a CFSSL signing policy JSON document.
+	CFSSLConfigFile string
+	// Profiles are the issuance policies this CA process will offer,
+	// keyed by name (e.g. "shortlived", "standard", "ev-blocked"). The RA
+	// selects among them per-request via IssueCertificate's profileName.
+	Profiles map[string]Profile
+	// DefaultProfile names the entry in Profiles to use when the caller
+	// does not specify one.
+	DefaultProfile string
+}
+
+// NewLocalCertificateAuthorityImpl creates a CA that signs certificates
+// itself using the issuer cert and key described by cfg, rather than
+// talking to a remote CFSSL instance.  This mirrors the local-signer
+// construction used by other CFSSL-based CAs (e.g. docker/swarmkit).
+func NewLocalCertificateAuthorityImpl(cfg LocalCAConfig) (*CertificateAuthorityImpl, error) {
+	issuerCertBytes, err := ioutil.ReadFile(cfg.IssuerCertFile)
+	if err != nil {
+		return nil, err
+	}
+	issuerCert, err := helpers.ParseCertificatePEM(issuerCertBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	issuerKey, err := cfg.KeySource.IssuerKey()
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := loadSigningPolicy(cfg.CFSSLConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	localSigner, err := local.NewSigner(issuerKey, issuerCert, signer.DefaultSigAlgo(issuerKey), policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CertificateAuthorityImpl{
+		Signer:         localSigner,
+		profiles:       cfg.Profiles,
+		defaultProfile: cfg.DefaultProfile,
+		issuerCert:     issuerCert,
+		issuerKey:      issuerKey,
+	}, nil
+}
+
+// loadSigningPolicy reads a CFSSL signing policy from disk.  An empty
+// filename falls back to CFSSL's default signing policy.  Either way, the
+// policy is widened with allowIssuanceExtensions before use, since
+// IssueCertificate needs to pass its own extensions through the signer
+// regardless of what's in the on-disk config.
+func loadSigningPolicy(filename string) (*config.Signing, error) {
+	var policy *config.Signing
+	if filename == "" {
+		policy = &config.Signing{Default: config.DefaultConfig()}
+	} else {
+		cfg, err := config.LoadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		policy = cfg.Signing
+	}
+	allowIssuanceExtensions(policy)
+	return policy, nil
+}
+
+// requiredExtensionOIDs are the custom certificate extensions
+// IssueCertificate may ask the signer to embed: the CRL Distribution
+// Points extension (see crlDistributionPointsExtension, used when a
+// profile sets CRLURL) and the CT poison/SCT-list extensions (used when
+// CTConfig is set). CFSSL's local signer drops any SignRequest.Extensions
+// not present in the selected profile's AllowedExtensions, so every
+// profile a local-signer CA might issue under needs these allow-listed up
+// front, not just the ones a given issuance happens to use.
+var requiredExtensionOIDs = []asn1.ObjectIdentifier{
+	crlDistributionPointsOID,
+	ct.PoisonExtensionOID,
+	ct.SCTListExtensionOID,
+}
+
+// allowIssuanceExtensions permits requiredExtensionOIDs on policy's
+// Default profile and every named profile in policy.Profiles.
+func allowIssuanceExtensions(policy *config.Signing) {
+	if policy == nil {
+		return
+	}
+	allow := func(p *config.SigningProfile) {
+		if p == nil {
+			return
+		}
+		for _, oid := range requiredExtensionOIDs {
+			p.AllowedExtensions = append(p.AllowedExtensions, config.OID(oid))
+		}
+	}
+	allow(policy.Default)
+	for _, p := range policy.Profiles {
+		allow(p)
+	}
+}
+
+// Ensure signer.Signer is satisfied by local.Signer at compile time, the
+// same way the remote signer is used in NewCertificateAuthorityImpl.
+var _ signer.Signer = (*local.Signer)(nil)
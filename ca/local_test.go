@@ -0,0 +1,188 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/ct"
+)
+
+// writeTestIssuerFiles generates a self-signed ECDSA issuer cert/key pair
+// — the key type this request exists to support via HSM-backed key
+// sources, and the one a hardcoded SHA256WithRSA signature algorithm would
+// silently fail to sign with — and writes both as PEM files under dir.
+func writeTestIssuerFiles(t *testing.T, dir string) (certFile, keyFile string, issuerCert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating issuer key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Local Issuer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour * 24 * 365),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating issuer cert: %s", err)
+	}
+	issuerCert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing issuer cert: %s", err)
+	}
+
+	certFile = filepath.Join(dir, "issuer.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("writing issuer cert: %s", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshaling issuer key: %s", err)
+	}
+	keyFile = filepath.Join(dir, "issuer-key.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("writing issuer key: %s", err)
+	}
+
+	return certFile, keyFile, issuerCert
+}
+
+// TestNewLocalCertificateAuthorityImplIssuesAndChains exercises the actual
+// local-signer flow this request added: an on-disk issuer cert loaded
+// through FileIssuerKeySource, wired into a real cfssl local.Signer by
+// NewLocalCertificateAuthorityImpl, rather than the hand-rolled fakeSigner
+// the rest of this package's tests use to isolate IssueCertificate's own
+// logic.
+func TestNewLocalCertificateAuthorityImplIssuesAndChains(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, issuerCert := writeTestIssuerFiles(t, dir)
+
+	localCA, err := NewLocalCertificateAuthorityImpl(LocalCAConfig{
+		IssuerCertFile: certFile,
+		KeySource:      FileIssuerKeySource{Filename: keyFile},
+		Profiles:       map[string]Profile{"default": {Name: "default"}},
+		DefaultProfile: "default",
+	})
+	if err != nil {
+		t.Fatalf("NewLocalCertificateAuthorityImpl: %s", err)
+	}
+	localCA.SA = &fakeSA{}
+
+	csr := testCSR(t, "example.com", []string{"example.com"})
+	authzs := []core.Authorization{
+		{
+			RegistrationID: 1,
+			Status:         core.StatusValid,
+			Expires:        time.Now().Add(time.Hour),
+			Identifier:     core.AcmeIdentifier{Type: core.IdentifierDNS, Value: "example.com"},
+		},
+	}
+
+	cert, err := localCA.IssueCertificate(csr, 1, authzs, "")
+	if err != nil {
+		t.Fatalf("IssueCertificate: %s", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.DER)
+	if err != nil {
+		t.Fatalf("parsing issued cert: %s", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(issuerCert)
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: "example.com"}); err != nil {
+		t.Fatalf("issued certificate did not chain to the on-disk issuer: %s", err)
+	}
+}
+
+func hasExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestNewLocalCertificateAuthorityImplEmbedsExtensions exercises the
+// extension-passthrough path the CRL-DP and CT features depend on: CFSSL's
+// local signer drops any SignRequest.Extensions the active profile doesn't
+// allow-list (see allowIssuanceExtensions), so this asserts the CRL-DP and
+// SCT-list extensions IssueCertificate builds actually survive a real
+// local.Signer round trip, not just the hand-rolled fakeSigner used
+// elsewhere in this package's tests.
+func TestNewLocalCertificateAuthorityImplEmbedsExtensions(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeTestIssuerFiles(t, dir)
+
+	localCA, err := NewLocalCertificateAuthorityImpl(LocalCAConfig{
+		IssuerCertFile: certFile,
+		KeySource:      FileIssuerKeySource{Filename: keyFile},
+		Profiles: map[string]Profile{
+			"default": {Name: "default", CRLURL: "http://crl.example.com/ca.crl"},
+		},
+		DefaultProfile: "default",
+	})
+	if err != nil {
+		t.Fatalf("NewLocalCertificateAuthorityImpl: %s", err)
+	}
+	localCA.SA = &fakeSA{}
+	localCA.CT = &CTConfig{
+		Submitter: ct.FakeSubmitter{SCT: ct.SCT{Version: 1, LogID: []byte("testlog-testlog-testlog-testlog-")}},
+		Logs: []ct.LogConfig{
+			{URL: "https://log-a.example.com", Operator: "OperatorA"},
+			{URL: "https://log-b.example.com", Operator: "OperatorB"},
+		},
+		MinSCTs: 2,
+	}
+
+	csr := testCSR(t, "example.com", []string{"example.com"})
+	authzs := []core.Authorization{
+		{
+			RegistrationID: 1,
+			Status:         core.StatusValid,
+			Expires:        time.Now().Add(time.Hour),
+			Identifier:     core.AcmeIdentifier{Type: core.IdentifierDNS, Value: "example.com"},
+		},
+	}
+
+	cert, err := localCA.IssueCertificate(csr, 1, authzs, "")
+	if err != nil {
+		t.Fatalf("IssueCertificate: %s", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.DER)
+	if err != nil {
+		t.Fatalf("parsing issued cert: %s", err)
+	}
+
+	if !hasExtension(leaf, crlDistributionPointsOID) {
+		t.Error("issued certificate is missing the CRL Distribution Points extension")
+	}
+	if !hasExtension(leaf, ct.SCTListExtensionOID) {
+		t.Error("issued certificate is missing the SCT list extension")
+	}
+	if hasExtension(leaf, ct.PoisonExtensionOID) {
+		t.Error("issued (non-pre-cert) certificate must not carry the CT poison extension")
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ca
+
+import (
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/cfssl/config"
+	"github.com/cloudflare/cfssl/signer"
+)
+
+// NameConstraints mirrors a subset of x509.Certificate's Name Constraints
+// fields. The CA enforces the DNS domain fields on its own, independent of
+// whatever the underlying CFSSL signer (local or remote) would otherwise
+// permit; the IP range and email fields are not checked (see
+// checkNameConstraints). An empty NameConstraints imposes no restriction.
+type NameConstraints struct {
+	PermittedDNSDomains []string
+	ExcludedDNSDomains  []string
+	PermittedIPRanges   []*net.IPNet
+	ExcludedIPRanges    []*net.IPNet
+	PermittedEmails     []string
+	ExcludedEmails      []string
+}
+
+// Profile describes one issuance policy a CA process can offer, e.g.
+// "shortlived", "standard", or "ev-blocked".  The RA selects a profile by
+// name on each IssueCertificate call; the CA enforces it regardless of
+// what the configured signer would otherwise allow.
+type Profile struct {
+	// Name is the CFSSL signing profile name to request from ca.Signer.
+	Name string
+	// MaxValidity bounds how long a certificate issued under this profile
+	// may be valid for.
+	MaxValidity time.Duration
+	// AllowedKeyTypes restricts which CSR public key algorithms this
+	// profile will sign, e.g. {"rsa", "ecdsa"}. An empty list allows any
+	// key type that otherwise passes checkCSRKey.
+	AllowedKeyTypes []string
+	// Constraints is applied to every hostname in the CSR before
+	// dispatching to the signer.
+	Constraints NameConstraints
+	// CRLURL, if set, is the CRL distribution point IssueCertificate
+	// embeds into certificates issued under this profile, via an explicit
+	// CRL Distribution Points extension (see crlDistributionPointsExtension).
+	CRLURL string
+}
+
+// matchesDomain reports whether name is domain or a subdomain of domain.
+func matchesDomain(name, domain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if name == domain {
+		return true
+	}
+	return strings.HasSuffix(name, "."+domain)
+}
+
+// checkNameConstraints verifies that every hostname in names satisfies nc's
+// permitted/excluded DNS domains.  nc's IP range and email fields are not
+// checked anywhere: validateCSR rejects CSRs with IP SANs, and CSR email
+// addresses are neither checked against nc nor rejected.
+func checkNameConstraints(nc NameConstraints, names []string) error {
+	for _, name := range names {
+		for _, excluded := range nc.ExcludedDNSDomains {
+			if matchesDomain(name, excluded) {
+				return errors.New("Name constraint violation: " + name + " is excluded by policy.")
+			}
+		}
+		if len(nc.PermittedDNSDomains) == 0 {
+			continue
+		}
+		var permitted bool
+		for _, domain := range nc.PermittedDNSDomains {
+			if matchesDomain(name, domain) {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			return errors.New("Name constraint violation: " + name + " is not within a permitted domain.")
+		}
+	}
+	return nil
+}
+
+// checkKeyType verifies that the CSR's key algorithm, as already validated
+// by checkCSRKey, is in profile's AllowedKeyTypes (if restricted).
+func checkKeyType(profile Profile, keyAlgorithm string) error {
+	if len(profile.AllowedKeyTypes) == 0 {
+		return nil
+	}
+	for _, allowed := range profile.AllowedKeyTypes {
+		if strings.EqualFold(allowed, keyAlgorithm) {
+			return nil
+		}
+	}
+	return errors.New("Key type " + keyAlgorithm + " is not allowed under profile " + profile.Name + ".")
+}
+
+// crlDistributionPointsOID is id-ce-cRLDistributionPoints (RFC 5280 §4.2.1.13).
+var crlDistributionPointsOID = asn1.ObjectIdentifier{2, 5, 29, 31}
+
+// distributionPoint and distributionPointName mirror the ASN.1 structures
+// the standard library's x509 package uses internally to marshal CRL
+// distribution points; they're reproduced here since IssueCertificate
+// builds this extension itself rather than going through x509.Certificate.
+type distributionPoint struct {
+	DistributionPoint distributionPointName `asn1:"optional,tag:0"`
+}
+
+type distributionPointName struct {
+	FullName []asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// crlDistributionPointsExtension builds a CRL Distribution Points
+// extension containing a single distribution point whose fullName is the
+// URI url.
+func crlDistributionPointsExtension(url string) (signer.Extension, error) {
+	der, err := asn1.Marshal([]distributionPoint{
+		{
+			DistributionPoint: distributionPointName{
+				FullName: []asn1.RawValue{
+					{Class: asn1.ClassContextSpecific, Tag: 6, Bytes: []byte(url)},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return signer.Extension{}, err
+	}
+	return signer.Extension{
+		ID:    config.OID(crlDistributionPointsOID),
+		Value: hex.EncodeToString(der),
+	}, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package crl publishes and serves sharded CRLs generated by a CA.
+package crl
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Generator signs one shard of a CRL. ca.CertificateAuthorityImpl
+// satisfies this interface via its GenerateCRLShard method; it is defined
+// here, rather than imported, so this package doesn't need to depend on
+// ca.
+type Generator interface {
+	GenerateCRLShard(ctx context.Context, shard, shards int, thisUpdate, nextUpdate time.Time) ([]byte, error)
+}
+
+// shardEntry is one published CRL shard, cached in memory for the
+// Responder to serve.
+type shardEntry struct {
+	der          []byte
+	lastModified time.Time
+}
+
+// Store holds the most recently published CRL for each shard.
+type Store struct {
+	mu     sync.RWMutex
+	shards map[int]shardEntry
+}
+
+// NewStore returns an empty Store.
+func NewStore() *Store {
+	return &Store{shards: make(map[int]shardEntry)}
+}
+
+func (s *Store) get(shard int) (shardEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.shards[shard]
+	return e, ok
+}
+
+func (s *Store) set(shard int, e shardEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shards[shard] = e
+}
+
+// Publisher periodically regenerates and publishes every CRL shard into a
+// Store, so Responder always has a fresh copy to serve.
+type Publisher struct {
+	Generator Generator
+	Store     *Store
+	Shards    int
+	// Validity is how long each generated CRL is valid for (NextUpdate =
+	// generation time + Validity).
+	Validity time.Duration
+	// TickInterval is how often shards are regenerated. It should be
+	// comfortably shorter than Validity so a missed tick doesn't let a
+	// shard go stale.
+	TickInterval time.Duration
+}
+
+// NewPublisher returns a Publisher that regenerates all of shards CRL
+// shards every tickInterval, each valid for validity.
+func NewPublisher(gen Generator, store *Store, shards int, validity, tickInterval time.Duration) *Publisher {
+	if shards < 1 {
+		shards = 1
+	}
+	return &Publisher{
+		Generator:    gen,
+		Store:        store,
+		Shards:       shards,
+		Validity:     validity,
+		TickInterval: tickInterval,
+	}
+}
+
+// Run publishes all shards once immediately, then again on every tick
+// until stop is closed.
+func (p *Publisher) Run(stop <-chan struct{}) {
+	p.publishAll()
+	ticker := time.NewTicker(p.TickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.publishAll()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (p *Publisher) publishAll() {
+	for shard := 0; shard < p.Shards; shard++ {
+		if err := p.publishOne(shard); err != nil {
+			log.Printf("crl: failed to publish shard %d/%d: %s", shard, p.Shards, err)
+		}
+	}
+}
+
+func (p *Publisher) publishOne(shard int) error {
+	thisUpdate := time.Now()
+	nextUpdate := thisUpdate.Add(p.Validity)
+	der, err := p.Generator.GenerateCRLShard(context.Background(), shard, p.Shards, thisUpdate, nextUpdate)
+	if err != nil {
+		return err
+	}
+	p.Store.set(shard, shardEntry{der: der, lastModified: thisUpdate})
+	return nil
+}
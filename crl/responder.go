@@ -0,0 +1,62 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package crl
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Responder serves the most recently published CRL shards over HTTP,
+// honoring If-Modified-Since so clients and CDNs don't re-fetch an
+// unchanged CRL.
+type Responder struct {
+	Store *Store
+}
+
+// NewResponder returns a Responder backed by store.
+func NewResponder(store *Store) *Responder {
+	return &Responder{Store: store}
+}
+
+// ServeHTTP serves GET /<shard>.crl, where <shard> is the shard number
+// (0 for an unsharded CRL).
+func (rs *Responder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	shardStr := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".crl")
+	shard, err := strconv.Atoi(shardStr)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	entry, ok := rs.Store.get(shard)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+		// HTTP dates have one-second precision, so compare at that
+		// precision; otherwise the Last-Modified value a client echoes back
+		// would always look older than the entry.
+		if !entry.lastModified.Truncate(time.Second).After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/pkix-crl")
+	w.Header().Set("Last-Modified", entry.lastModified.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+	w.Write(entry.der)
+}
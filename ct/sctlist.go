@@ -0,0 +1,83 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ct
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+)
+
+// PoisonExtensionOID is the critical poison extension (RFC 6962 §3.1)
+// that marks a certificate as a pre-certificate, never to be trusted as a
+// final certificate by relying parties.
+var PoisonExtensionOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+
+// SCTListExtensionOID is the non-critical extension (RFC 6962 §3.3) that
+// carries the embedded SCT list in a final certificate.
+var SCTListExtensionOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// PoisonExtensionValue is the DER encoding of the poison extension's
+// value: an ASN.1 NULL.
+var PoisonExtensionValue = []byte{0x05, 0x00}
+
+// serializeSCT TLS-encodes a single SCT as specified in RFC 6962 §3.2.
+// The Signature field is expected to already be in RFC 5246
+// digitally-signed-struct form, as returned by a log's add-pre-chain
+// response, so it is appended verbatim.
+func serializeSCT(sct SCT) ([]byte, error) {
+	if len(sct.LogID) != 32 {
+		return nil, errors.New("ct: log ID must be 32 bytes")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(sct.Version)
+	buf.Write(sct.LogID)
+	if err := binary.Write(&buf, binary.BigEndian, sct.Timestamp); err != nil {
+		return nil, err
+	}
+	if len(sct.Extensions) > 0xffff {
+		return nil, errors.New("ct: SCT extensions too large")
+	}
+	if err := binary.Write(&buf, binary.BigEndian, uint16(len(sct.Extensions))); err != nil {
+		return nil, err
+	}
+	buf.Write(sct.Extensions)
+	buf.Write(sct.Signature)
+	return buf.Bytes(), nil
+}
+
+// EncodeSCTListExtensionValue builds the pkix.Extension.Value for the SCT
+// list extension: a DER OCTET STRING wrapping the TLS-encoded
+// SignedCertificateTimestampList built from scts.
+func EncodeSCTListExtensionValue(scts []SCT) ([]byte, error) {
+	var list bytes.Buffer
+	for _, sct := range scts {
+		serialized, err := serializeSCT(sct)
+		if err != nil {
+			return nil, err
+		}
+		if len(serialized) > 0xffff {
+			return nil, errors.New("ct: serialized SCT too large")
+		}
+		if err := binary.Write(&list, binary.BigEndian, uint16(len(serialized))); err != nil {
+			return nil, err
+		}
+		list.Write(serialized)
+	}
+
+	if list.Len() > 0xffff {
+		return nil, errors.New("ct: SCT list too large")
+	}
+	var wrapped bytes.Buffer
+	if err := binary.Write(&wrapped, binary.BigEndian, uint16(list.Len())); err != nil {
+		return nil, err
+	}
+	wrapped.Write(list.Bytes())
+
+	return asn1.Marshal(wrapped.Bytes())
+}
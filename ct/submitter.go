@@ -0,0 +1,227 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package ct submits pre-certificates to Certificate Transparency logs
+// and collects the resulting Signed Certificate Timestamps (SCTs), per
+// RFC 6962.
+package ct
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SCT is a Signed Certificate Timestamp, as defined in RFC 6962 §3.2.
+type SCT struct {
+	Version   uint8
+	LogID     []byte
+	Timestamp uint64
+	Extensions []byte
+	Signature []byte
+}
+
+// LogConfig identifies one CT log to submit pre-certificates to.
+type LogConfig struct {
+	// URL is the log's base URL, e.g. "https://ct.example.com/log".
+	URL string
+	// Operator names the organization that runs this log, used only to
+	// help operators reason about "independent operator" diversity when
+	// setting MinSCTs.
+	Operator string
+	// Timeout bounds a single submission to this log.
+	Timeout time.Duration
+}
+
+// CTSubmitter submits a pre-certificate to a single CT log and returns the
+// resulting SCT.
+type CTSubmitter interface {
+	SubmitPreChain(ctx context.Context, log LogConfig, preCertDER []byte, issuerDER []byte) (SCT, error)
+}
+
+// HTTPSubmitter is a CTSubmitter that speaks the real RFC 6962
+// add-pre-chain HTTP API.
+type HTTPSubmitter struct {
+	Client *http.Client
+}
+
+// NewHTTPSubmitter returns an HTTPSubmitter using client, or
+// http.DefaultClient if client is nil.
+func NewHTTPSubmitter(client *http.Client) *HTTPSubmitter {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPSubmitter{Client: client}
+}
+
+type addChainRequest struct {
+	Chain []string `json:"chain"`
+}
+
+type addChainResponse struct {
+	SCTVersion uint8  `json:"sct_version"`
+	ID         string `json:"id"`
+	Timestamp  uint64 `json:"timestamp"`
+	Extensions string `json:"extensions"`
+	Signature  string `json:"signature"`
+}
+
+// SubmitPreChain implements CTSubmitter by POSTing to
+// <log.URL>/ct/v1/add-pre-chain.
+func (h *HTTPSubmitter) SubmitPreChain(ctx context.Context, log LogConfig, preCertDER []byte, issuerDER []byte) (SCT, error) {
+	body, err := json.Marshal(addChainRequest{
+		Chain: []string{
+			base64.StdEncoding.EncodeToString(preCertDER),
+			base64.StdEncoding.EncodeToString(issuerDER),
+		},
+	})
+	if err != nil {
+		return SCT{}, err
+	}
+
+	req, err := http.NewRequest("POST", log.URL+"/ct/v1/add-pre-chain", bytes.NewReader(body))
+	if err != nil {
+		return SCT{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(ctx)
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return SCT{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return SCT{}, fmt.Errorf("ct: log %s returned status %d", log.URL, resp.StatusCode)
+	}
+
+	var parsed addChainResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return SCT{}, err
+	}
+
+	logID, err := base64.StdEncoding.DecodeString(parsed.ID)
+	if err != nil {
+		return SCT{}, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(parsed.Signature)
+	if err != nil {
+		return SCT{}, err
+	}
+	var ext []byte
+	if parsed.Extensions != "" {
+		ext, err = base64.StdEncoding.DecodeString(parsed.Extensions)
+		if err != nil {
+			return SCT{}, err
+		}
+	}
+
+	return SCT{
+		Version:    parsed.SCTVersion,
+		LogID:      logID,
+		Timestamp:  parsed.Timestamp,
+		Extensions: ext,
+		Signature:  sig,
+	}, nil
+}
+
+// FakeSubmitter is a CTSubmitter that never makes a network call. It
+// exists for tests that exercise the quorum-collection logic in
+// ca.CertificateAuthorityImpl without standing up real CT logs.
+type FakeSubmitter struct {
+	// SCT is returned for every call, unless Err is set.
+	SCT SCT
+	// Err, if set, is returned instead of SCT.
+	Err error
+}
+
+// SubmitPreChain implements CTSubmitter.
+func (f FakeSubmitter) SubmitPreChain(ctx context.Context, log LogConfig, preCertDER []byte, issuerDER []byte) (SCT, error) {
+	if f.Err != nil {
+		return SCT{}, f.Err
+	}
+	return f.SCT, nil
+}
+
+// submissionResult pairs an SCT with the log it came from, for fan-out
+// bookkeeping in SubmitToLogs.
+type submissionResult struct {
+	log LogConfig
+	sct SCT
+	err error
+}
+
+// operatorKey returns the identity SubmitToLogs dedupes quorum on: a log's
+// Operator if set, falling back to its URL so logs from unconfigured
+// operators still each count toward quorum individually rather than not
+// counting at all.
+func operatorKey(l LogConfig) string {
+	if l.Operator != "" {
+		return l.Operator
+	}
+	return l.URL
+}
+
+// SubmitToLogs submits preCertDER to every log in logs in parallel, each
+// bounded by its own Timeout, and returns as soon as SCTs from minSCTs
+// distinct operators have come back successfully, or deadline elapses,
+// whichever comes first. Two logs run by the same operator only ever
+// count once toward quorum, so "default 2 from independent operators" is
+// actually enforced rather than merely documented. It returns an error if
+// quorum was not reached in time.
+func SubmitToLogs(ctx context.Context, submitter CTSubmitter, logs []LogConfig, preCertDER, issuerDER []byte, minSCTs int, deadline time.Duration) ([]SCT, error) {
+	operators := make(map[string]bool, len(logs))
+	for _, l := range logs {
+		operators[operatorKey(l)] = true
+	}
+	if len(operators) < minSCTs {
+		return nil, errors.New("ct: fewer independent log operators configured than the required SCT quorum")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+
+	results := make(chan submissionResult, len(logs))
+	for _, l := range logs {
+		go func(l LogConfig) {
+			logCtx := ctx
+			if l.Timeout > 0 {
+				var logCancel context.CancelFunc
+				logCtx, logCancel = context.WithTimeout(ctx, l.Timeout)
+				defer logCancel()
+			}
+			sct, err := submitter.SubmitPreChain(logCtx, l, preCertDER, issuerDER)
+			results <- submissionResult{log: l, sct: sct, err: err}
+		}(l)
+	}
+
+	var scts []SCT
+	seenOperators := make(map[string]bool, len(logs))
+	for i := 0; i < len(logs); i++ {
+		select {
+		case r := <-results:
+			if r.err == nil {
+				scts = append(scts, r.sct)
+				seenOperators[operatorKey(r.log)] = true
+				if len(seenOperators) >= minSCTs {
+					return scts, nil
+				}
+			}
+		case <-ctx.Done():
+			return nil, fmt.Errorf("ct: only got SCTs from %d/%d required independent operators before deadline", len(seenOperators), minSCTs)
+		}
+	}
+
+	if len(seenOperators) < minSCTs {
+		return nil, fmt.Errorf("ct: only got SCTs from %d/%d required independent operators", len(seenOperators), minSCTs)
+	}
+	return scts, nil
+}
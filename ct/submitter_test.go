@@ -0,0 +1,111 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ct
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeLogSubmitter is a CTSubmitter that hands back a preconfigured SCT or
+// error per log URL, so tests can control which logs succeed.
+type fakeLogSubmitter struct {
+	// results maps a log's URL to the SCT/error it should hand back.
+	results map[string]submissionResult
+}
+
+func (f fakeLogSubmitter) SubmitPreChain(ctx context.Context, log LogConfig, preCertDER, issuerDER []byte) (SCT, error) {
+	r, ok := f.results[log.URL]
+	if !ok {
+		return SCT{}, errors.New("ct: no fake result configured for log " + log.URL)
+	}
+	return r.sct, r.err
+}
+
+func TestSubmitToLogsDedupesQuorumByOperator(t *testing.T) {
+	logs := []LogConfig{
+		{URL: "https://a1.example.com", Operator: "OperatorA"},
+		{URL: "https://a2.example.com", Operator: "OperatorA"},
+		{URL: "https://b1.example.com", Operator: "OperatorB"},
+	}
+	submitter := fakeLogSubmitter{results: map[string]submissionResult{
+		"https://a1.example.com": {sct: SCT{Version: 1, LogID: []byte("a1")}},
+		"https://a2.example.com": {sct: SCT{Version: 1, LogID: []byte("a2")}},
+		"https://b1.example.com": {sct: SCT{Version: 1, LogID: []byte("b1")}},
+	}}
+
+	scts, err := SubmitToLogs(context.Background(), submitter, logs, nil, nil, 2, time.Second)
+	if err != nil {
+		t.Fatalf("SubmitToLogs: %s", err)
+	}
+	if len(scts) < 2 {
+		t.Fatalf("got %d SCTs, want at least 2", len(scts))
+	}
+	// The two OperatorA logs count once, so quorum needs OperatorB's SCT.
+	var sawB bool
+	for _, sct := range scts {
+		if string(sct.LogID) == "b1" {
+			sawB = true
+		}
+	}
+	if !sawB {
+		t.Errorf("quorum reached without OperatorB's SCT; logs of one operator must count once")
+	}
+}
+
+func TestSubmitToLogsRejectsInsufficientOperators(t *testing.T) {
+	logs := []LogConfig{
+		{URL: "https://a1.example.com", Operator: "OperatorA"},
+		{URL: "https://a2.example.com", Operator: "OperatorA"},
+	}
+	submitter := fakeLogSubmitter{results: map[string]submissionResult{
+		"https://a1.example.com": {sct: SCT{Version: 1, LogID: []byte("a1")}},
+		"https://a2.example.com": {sct: SCT{Version: 1, LogID: []byte("a2")}},
+	}}
+
+	// Both logs are run by the same operator, so a quorum of 2 independent
+	// operators is unsatisfiable no matter how many of them succeed.
+	if _, err := SubmitToLogs(context.Background(), submitter, logs, nil, nil, 2, time.Second); err == nil {
+		t.Fatal("SubmitToLogs succeeded with only one distinct operator configured, want error")
+	}
+}
+
+func TestSubmitToLogsToleratesOneFailure(t *testing.T) {
+	logs := []LogConfig{
+		{URL: "https://a1.example.com", Operator: "OperatorA"},
+		{URL: "https://b1.example.com", Operator: "OperatorB"},
+		{URL: "https://c1.example.com", Operator: "OperatorC"},
+	}
+	submitter := fakeLogSubmitter{results: map[string]submissionResult{
+		"https://a1.example.com": {err: errors.New("log unavailable")},
+		"https://b1.example.com": {sct: SCT{Version: 1, LogID: []byte("b1")}},
+		"https://c1.example.com": {sct: SCT{Version: 1, LogID: []byte("c1")}},
+	}}
+
+	scts, err := SubmitToLogs(context.Background(), submitter, logs, nil, nil, 2, time.Second)
+	if err != nil {
+		t.Fatalf("SubmitToLogs: %s", err)
+	}
+	if len(scts) < 2 {
+		t.Fatalf("got %d SCTs, want at least 2", len(scts))
+	}
+}
+
+// Exercise FakeSubmitter itself, since ca's CT-configured tests use it as
+// their CTSubmitter double.
+func TestFakeSubmitterReturnsConfiguredSCT(t *testing.T) {
+	want := SCT{Version: 1, LogID: []byte("fake")}
+	f := FakeSubmitter{SCT: want}
+	got, err := f.SubmitPreChain(context.Background(), LogConfig{URL: "https://fake.example.com"}, nil, nil)
+	if err != nil {
+		t.Fatalf("SubmitPreChain: %s", err)
+	}
+	if string(got.LogID) != string(want.LogID) {
+		t.Errorf("got LogID %q, want %q", got.LogID, want.LogID)
+	}
+}
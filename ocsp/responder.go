@@ -0,0 +1,95 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ocsp
+
+import (
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/letsencrypt/boulder/core"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// maxRequestSize is the largest OCSP request body the responder will
+// read, per RFC 6960 ordinary requests are only a few hundred bytes.
+const maxRequestSize = 8192
+
+// Responder serves pre-signed OCSP responses over HTTP, per RFC 6960's
+// GET profile: GET requests carry the standard (not URL-safe) base64 DER
+// request as the URL path, POST requests carry it as the raw request
+// body. Responses are served with Content-Type: application/ocsp-response
+// and a Cache-Control header so a CDN can front the responder.
+type Responder struct {
+	Source core.OCSPResponder
+}
+
+// NewResponder returns a Responder backed by source.
+func NewResponder(source core.OCSPResponder) *Responder {
+	return &Responder{Source: source}
+}
+
+func (rs *Responder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var reqBytes []byte
+	var err error
+
+	switch r.Method {
+	case "GET":
+		reqBytes, err = decodeGETRequest(r.URL.Path)
+	case "POST":
+		reqBytes, err = ioutil.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil || len(reqBytes) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ocspReq, err := ocsp.ParseRequest(reqBytes)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	response, err := rs.Source.Response(ocspReq.SerialNumber.Bytes())
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Header().Set("Cache-Control", "public, max-age=3600, no-transform, must-revalidate")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// decodeGETRequest recovers the standard-base64-encoded DER request from
+// an RFC 6960 GET URL path. The base64 alphabet includes "/" and "+",
+// which clients are required to percent-encode when embedding it in a
+// path segment; net/http's URL.Path is already percent-decoded, so a
+// properly-escaped "/" or "+" arrives here as a literal character and
+// decodes directly, as does one a client left unescaped, since path
+// decoding leaves a literal "+" as it is. Only if that direct decode fails
+// is the path unescaped once more with url.QueryUnescape, which serves
+// clients that percent-encoded the request twice; that step also turns
+// any literal "+" into a space.
+func decodeGETRequest(path string) ([]byte, error) {
+	encoded := strings.TrimPrefix(path, "/")
+	if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+		return decoded, nil
+	}
+	unescaped, err := url.QueryUnescape(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(unescaped)
+}
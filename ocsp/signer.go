@@ -0,0 +1,105 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package ocsp implements a built-in OCSP responder subsystem for
+// Boulder.  It reuses the CA's issuer identity to sign OCSP responses,
+// stores them in the SA keyed by serial, and serves them over HTTP.
+package ocsp
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+
+	"github.com/cloudflare/cfssl/helpers"
+	cfocsp "github.com/cloudflare/cfssl/ocsp"
+)
+
+// OCSPSigner signs OCSP responses on behalf of a single issuer, using the
+// responder certificate it was constructed with, which may be the issuer's
+// own certificate or a dedicated responder cert.
+type OCSPSigner struct {
+	signer   cfocsp.Signer
+	Interval time.Duration
+}
+
+// NewOCSPSignerFromFile creates an OCSPSigner from PEM files on disk: the
+// CA's issuer certificate, the (optionally distinct) OCSP responder
+// certificate, and the responder's private key.  interval is the update
+// window used for freshly signed "good" responses (NextUpdate = now +
+// interval).
+func NewOCSPSignerFromFile(issuerFile, responderFile, keyFile string, interval time.Duration) (*OCSPSigner, error) {
+	issuerBytes, err := ioutil.ReadFile(issuerFile)
+	if err != nil {
+		return nil, err
+	}
+	issuerCert, err := helpers.ParseCertificatePEM(issuerBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	responderBytes, err := ioutil.ReadFile(responderFile)
+	if err != nil {
+		return nil, err
+	}
+	responderCert, err := helpers.ParseCertificatePEM(responderBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	keyBytes, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := helpers.ParsePrivateKeyPEM(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	cfSigner, err := cfocsp.NewSigner(issuerCert, responderCert, key, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OCSPSigner{signer: cfSigner, Interval: interval}, nil
+}
+
+// SignGood signs a fresh "good" OCSP response for cert, with ThisUpdate
+// set to now and NextUpdate set to now+Interval. cfssl's OCSP signer needs
+// the full certificate, not just its serial, to build the response (it
+// reads the issuer hash and any OCSP-relevant extensions off of it).
+func (s *OCSPSigner) SignGood(cert *x509.Certificate) ([]byte, error) {
+	return s.signer.Sign(cfocsp.SignRequest{
+		Certificate: cert,
+		Status:      "good",
+	})
+}
+
+// SignRevoked signs an OCSP response marking cert revoked as of
+// revokedAt, for the given CRL reason code.
+func (s *OCSPSigner) SignRevoked(cert *x509.Certificate, revokedAt time.Time, reason int) ([]byte, error) {
+	return s.signer.Sign(cfocsp.SignRequest{
+		Certificate: cert,
+		Status:      "revoked",
+		Reason:      reason,
+		RevokedAt:   revokedAt,
+	})
+}
+
+// Store implements core.OCSPResponder by looking up pre-signed responses
+// already persisted in the SA, rather than signing on every request.
+// This is what the HTTP handler talks to, so that a CDN fronting the
+// responder only ever serves cached bytes.
+type Store struct {
+	SA core.StorageAuthority
+}
+
+// Response returns the pre-signed OCSP response for serial, as stored by
+// the CA at issuance time or refreshed by Updater.
+func (s Store) Response(serial []byte) ([]byte, error) {
+	return s.SA.GetOCSPResponse(serial)
+}
@@ -0,0 +1,87 @@
+// Copyright 2014 ISRG.  All rights reserved
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ocsp
+
+import (
+	"crypto/x509"
+	"log"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+)
+
+// Updater periodically re-signs OCSP responses that are approaching their
+// NextUpdate, and signs fresh revoked responses for certificates the SA
+// has newly marked as StatusRevoked.
+type Updater struct {
+	SA     core.StorageAuthority
+	Signer *OCSPSigner
+	// RefreshWindow is how long before a response's NextUpdate the
+	// Updater will re-sign it.
+	RefreshWindow time.Duration
+	// TickInterval is how often the Updater polls the SA for work.
+	TickInterval time.Duration
+}
+
+// NewUpdater returns an Updater that re-signs responses due to expire
+// within refreshWindow, polling the SA every tickInterval.
+func NewUpdater(sa core.StorageAuthority, signer *OCSPSigner, refreshWindow, tickInterval time.Duration) *Updater {
+	return &Updater{
+		SA:            sa,
+		Signer:        signer,
+		RefreshWindow: refreshWindow,
+		TickInterval:  tickInterval,
+	}
+}
+
+// Run polls on TickInterval until stop is closed, refreshing responses and
+// marking revoked certs on each tick.
+func (u *Updater) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(u.TickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := u.tick(); err != nil {
+				log.Printf("ocsp: update tick failed: %s", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// tick re-signs any response nearing expiry and signs fresh revoked
+// responses for certs the SA has marked StatusRevoked since the last tick.
+func (u *Updater) tick() error {
+	stale, err := u.SA.GetCertificatesNearOCSPExpiry(u.RefreshWindow)
+	if err != nil {
+		return err
+	}
+	for _, c := range stale {
+		parsed, err := x509.ParseCertificate(c.DER)
+		if err != nil {
+			log.Printf("ocsp: failed to parse stored certificate: %s", err)
+			continue
+		}
+
+		var response []byte
+		var signErr error
+		if c.Status == core.StatusRevoked {
+			response, signErr = u.Signer.SignRevoked(parsed, c.RevokedAt, c.RevocationReason)
+		} else {
+			response, signErr = u.Signer.SignGood(parsed)
+		}
+		if signErr != nil {
+			log.Printf("ocsp: failed to re-sign serial %s: %s", parsed.SerialNumber, signErr)
+			continue
+		}
+		if err := u.SA.AddOCSPResponse(parsed.SerialNumber.Bytes(), response, time.Now().Add(u.Signer.Interval)); err != nil {
+			log.Printf("ocsp: failed to store refreshed response for serial %s: %s", parsed.SerialNumber, err)
+		}
+	}
+	return nil
+}